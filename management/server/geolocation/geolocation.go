@@ -1,7 +1,6 @@
 package geolocation
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"net"
@@ -9,23 +8,40 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
-	"sync"
-	"time"
 
-	"github.com/oschwald/maxminddb-golang"
 	log "github.com/sirupsen/logrus"
 )
 
-type Geolocation struct {
-	mmdbPath            string
-	mux                 sync.RWMutex
-	sha256sum           []byte
-	db                  *maxminddb.Reader
-	locationDB          *SqliteStore
-	stopCh              chan struct{}
-	reloadCheckInterval time.Duration
-}
+// Geolocation resolves an IP address to geographic information and exposes
+// the lookup tables used to populate posture check UIs. The MaxMind GeoLite2
+// implementation was the only backend for a long time, but MaxMind's
+// licensing changes have pushed a number of operators towards alternative
+// mmdb/BIN providers, so the concrete databases are now swappable behind
+// this interface.
+type Geolocation interface {
+	Lookup(ip net.IP) (*Record, error)
+	GetAllCountries() ([]Country, error)
+	GetCitiesByCountry(countryISOCode string) ([]City, error)
+	// LookupASN returns the autonomous system number and organization name
+	// for ip. Providers without ASN enrichment configured return an error.
+	LookupASN(ip net.IP) (asn uint, org string, err error)
+	Stop() error
+}
+
+// GeoProvider selects which geolocation database backend NewGeolocation
+// constructs.
+type GeoProvider string
 
+const (
+	ProviderMaxMind     GeoProvider = "maxmind"
+	ProviderIP2Location GeoProvider = "ip2location"
+	ProviderDBIP        GeoProvider = "dbip"
+)
+
+// Record is a provider-normalized geolocation lookup result. Country.ISOCode
+// is always populated; City.GeonameID is zero for providers whose database
+// doesn't carry geoname IDs (e.g. IP2Location, which only fills in the city
+// name, City.Names.En).
 type Record struct {
 	City struct {
 		GeonameID uint `maxminddb:"geoname_id"`
@@ -41,6 +57,15 @@ type Record struct {
 		GeonameID uint   `maxminddb:"geoname_id"`
 		ISOCode   string `maxminddb:"iso_code"`
 	} `maxminddb:"country"`
+
+	// ASN, ASOrganization and the Is* flags are only populated when the
+	// provider was configured with an ASN and/or anonymous-IP database;
+	// otherwise they're left at their zero values.
+	ASN                 uint   `maxminddb:"autonomous_system_number"`
+	ASOrganization      string `maxminddb:"autonomous_system_organization"`
+	IsAnonymousProxy    bool   `maxminddb:"is_anonymous"`
+	IsSatelliteProvider bool   `maxminddb:"is_satellite_provider"`
+	IsHostingProvider   bool   `maxminddb:"is_hosting_provider"`
 }
 
 type City struct {
@@ -53,209 +78,32 @@ type Country struct {
 	CountryName    string
 }
 
-const (
-	mmdbPattern           = "GeoLite2-City-maxmind_*.mmdb"
-	geonamesdbPattern     = "GeoLite2-City-geonames_*.db"
-	oldMMDBFilename       = "GeoLite2-City.mmdb"
-	oldGeoNamesDBFilename = "geonames.db"
-)
-
-func NewGeolocation(ctx context.Context, dataDir string, mmdbFile string, geonamesdbFile string) (*Geolocation, error) {
-	if err := loadGeolocationDatabases(dataDir, mmdbFile, geonamesdbFile); err != nil {
-		return nil, fmt.Errorf("failed to load MaxMind databases: %v", err)
-	}
-
-	if err := cleanupMaxMindDatabases(dataDir, mmdbFile, geonamesdbFile); err != nil {
-		return nil, fmt.Errorf("failed to remove old MaxMind databases: %v", err)
-	}
-
-	mmdbPath := path.Join(dataDir, mmdbFile)
-	db, err := openDB(mmdbPath)
-	if err != nil {
-		return nil, err
-	}
-
-	sha256sum, err := calculateFileSHA256(mmdbPath)
-	if err != nil {
-		return nil, err
-	}
-
-	locationDB, err := NewSqliteStore(ctx, dataDir, geonamesdbFile)
-	if err != nil {
-		return nil, err
-	}
-
-	geo := &Geolocation{
-		mmdbPath:            mmdbPath,
-		mux:                 sync.RWMutex{},
-		sha256sum:           sha256sum,
-		db:                  db,
-		locationDB:          locationDB,
-		reloadCheckInterval: 300 * time.Second, // TODO: make configurable
-		stopCh:              make(chan struct{}),
-	}
-
-	go geo.reloader(ctx)
-
-	return geo, nil
-}
-
-func GetMaxMindFilenames(dataDir string, autoUpdate bool) (string, string) {
-	mmdbGlobPattern := path.Join(dataDir, mmdbPattern)
-	mmdbFilename, err := getDatabaseFilename(geoLiteCityTarGZURL, mmdbGlobPattern, autoUpdate)
-	if err != nil {
-		log.Warnf("Failed to get MaxMind database filename. Using old version, %s: %v", oldMMDBFilename, err)
-		mmdbFilename = oldMMDBFilename
-	}
-	geonamesdbGlobPattern := path.Join(dataDir, geonamesdbPattern)
-	geonamesdbFilename, err := getDatabaseFilename(geoLiteCityZipURL, geonamesdbGlobPattern, autoUpdate)
-	if err != nil {
-		log.Warnf("Failed to get GeoNames database filename. Using old version, %s: %v", oldGeoNamesDBFilename, err)
-		geonamesdbFilename = oldGeoNamesDBFilename
-	}
-
-	return mmdbFilename, geonamesdbFilename
-}
-
-func openDB(mmdbPath string) (*maxminddb.Reader, error) {
-	_, err := os.Stat(mmdbPath)
-
-	if os.IsNotExist(err) {
-		return nil, fmt.Errorf("%v does not exist", mmdbPath)
-	} else if err != nil {
-		return nil, err
-	}
-
-	db, err := maxminddb.Open(mmdbPath)
-	if err != nil {
-		return nil, fmt.Errorf("%v could not be opened: %w", mmdbPath, err)
-	}
-
-	return db, nil
-}
-
-func (gl *Geolocation) Lookup(ip net.IP) (*Record, error) {
-	gl.mux.RLock()
-	defer gl.mux.RUnlock()
-
-	var record Record
-	err := gl.db.Lookup(ip, &record)
-	if err != nil {
-		return nil, err
-	}
-
-	return &record, nil
-}
-
-// GetAllCountries retrieves a list of all countries.
-func (gl *Geolocation) GetAllCountries() ([]Country, error) {
-	allCountries, err := gl.locationDB.GetAllCountries()
-	if err != nil {
-		return nil, err
-	}
-
-	countries := make([]Country, 0)
-	for _, country := range allCountries {
-		if country.CountryName != "" {
-			countries = append(countries, country)
-		}
-	}
-	return countries, nil
-}
-
-// GetCitiesByCountry retrieves a list of cities in a specific country based on the country's ISO code.
-func (gl *Geolocation) GetCitiesByCountry(countryISOCode string) ([]City, error) {
-	allCities, err := gl.locationDB.GetCitiesByCountry(countryISOCode)
-	if err != nil {
-		return nil, err
-	}
-
-	cities := make([]City, 0)
-	for _, city := range allCities {
-		if city.CityName != "" {
-			cities = append(cities, city)
-		}
-	}
-	return cities, nil
-}
-
-func (gl *Geolocation) Stop() error {
-	close(gl.stopCh)
-	if gl.db != nil {
-		if err := gl.db.Close(); err != nil {
-			return err
+// NewGeolocation constructs the configured Geolocation backend. mmdbFile and
+// geonamesdbFile name the primary database and, for MaxMind, the geonames
+// SQLite companion database; for the other providers geonamesdbFile is
+// ignored as their BIN/mmdb files are self-contained.
+//
+// asnMmdbFile and anonMmdbFile optionally name a GeoLite2-ASN and
+// GeoLite2-Anonymous-IP (or equivalent) mmdb to enrich lookups with ASN and
+// anonymous-proxy data. They're only honored by the MaxMind provider; pass
+// empty strings to disable the enrichment or when using another provider.
+func NewGeolocation(ctx context.Context, provider GeoProvider, dataDir string, mmdbFile string, geonamesdbFile string, asnMmdbFile string, anonMmdbFile string) (Geolocation, error) {
+	switch provider {
+	case "", ProviderMaxMind:
+		return newMaxMindGeolocation(ctx, dataDir, mmdbFile, geonamesdbFile, asnMmdbFile, anonMmdbFile)
+	case ProviderIP2Location:
+		if asnMmdbFile != "" || anonMmdbFile != "" {
+			log.Warnf("ASN/anonymous-IP enrichment is only supported by the maxmind provider, ignoring")
 		}
-	}
-	if gl.locationDB != nil {
-		if err := gl.locationDB.close(); err != nil {
-			return err
+		return newIP2LocationGeolocation(ctx, dataDir, mmdbFile)
+	case ProviderDBIP:
+		if asnMmdbFile != "" || anonMmdbFile != "" {
+			log.Warnf("ASN/anonymous-IP enrichment is only supported by the maxmind provider, ignoring")
 		}
+		return newDBIPGeolocation(ctx, dataDir, mmdbFile)
+	default:
+		return nil, fmt.Errorf("unsupported geolocation provider: %s", provider)
 	}
-	return nil
-}
-
-func (gl *Geolocation) reloader(ctx context.Context) {
-	for {
-		select {
-		case <-gl.stopCh:
-			return
-		case <-time.After(gl.reloadCheckInterval):
-			if err := gl.locationDB.reload(ctx); err != nil {
-				log.WithContext(ctx).Errorf("geonames db reload failed: %s", err)
-			}
-
-			newSha256sum1, err := calculateFileSHA256(gl.mmdbPath)
-			if err != nil {
-				log.WithContext(ctx).Errorf("failed to calculate sha256 sum for '%s': %s", gl.mmdbPath, err)
-				continue
-			}
-			if !bytes.Equal(gl.sha256sum, newSha256sum1) {
-				// we check sum twice just to avoid possible case when we reload during update of the file
-				// considering the frequency of file update (few times a week) checking sum twice should be enough
-				time.Sleep(50 * time.Millisecond)
-				newSha256sum2, err := calculateFileSHA256(gl.mmdbPath)
-				if err != nil {
-					log.WithContext(ctx).Errorf("failed to calculate sha256 sum for '%s': %s", gl.mmdbPath, err)
-					continue
-				}
-				if !bytes.Equal(newSha256sum1, newSha256sum2) {
-					log.WithContext(ctx).Errorf("sha256 sum changed during reloading of '%s'", gl.mmdbPath)
-					continue
-				}
-				err = gl.reload(ctx, newSha256sum2)
-				if err != nil {
-					log.WithContext(ctx).Errorf("mmdb reload failed: %s", err)
-				}
-			} else {
-				log.WithContext(ctx).Tracef("No changes in '%s', no need to reload. Next check is in %.0f seconds.",
-					gl.mmdbPath, gl.reloadCheckInterval.Seconds())
-			}
-		}
-	}
-}
-
-func (gl *Geolocation) reload(ctx context.Context, newSha256sum []byte) error {
-	gl.mux.Lock()
-	defer gl.mux.Unlock()
-
-	log.WithContext(ctx).Infof("Reloading '%s'", gl.mmdbPath)
-
-	err := gl.db.Close()
-	if err != nil {
-		return err
-	}
-
-	db, err := openDB(gl.mmdbPath)
-	if err != nil {
-		return err
-	}
-
-	gl.db = db
-	gl.sha256sum = newSha256sum
-
-	log.WithContext(ctx).Infof("Successfully reloaded '%s'", gl.mmdbPath)
-
-	return nil
 }
 
 func fileExists(filePath string) (bool, error) {
@@ -321,21 +169,3 @@ func cleanupOldDatabases(pattern string, currentFile string) error {
 	}
 	return nil
 }
-
-func cleanupMaxMindDatabases(dataDir string, mmdbFile string, geonamesdbFile string) error {
-	for _, file := range []string{mmdbFile, geonamesdbFile} {
-		switch file {
-		case mmdbFile:
-			pattern := path.Join(dataDir, mmdbPattern)
-			if err := cleanupOldDatabases(pattern, file); err != nil {
-				return err
-			}
-		case geonamesdbFile:
-			pattern := path.Join(dataDir, geonamesdbPattern)
-			if err := cleanupOldDatabases(pattern, file); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
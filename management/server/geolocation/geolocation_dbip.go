@@ -0,0 +1,96 @@
+package geolocation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	dbipPattern = "dbip-city-lite-*.mmdb"
+)
+
+// dbipGeolocation is a DB-IP backed Geolocation implementation. DB-IP ships
+// its City Lite database in the same mmdb container format as MaxMind's
+// GeoLite2, with a schema close enough that it can be decoded straight into
+// Record, so this is mostly a thin wrapper with its own filename pattern and
+// reload loop.
+type dbipGeolocation struct {
+	mux                 sync.RWMutex
+	db                  *reloadableDB[*maxminddb.Reader]
+	stopCh              chan struct{}
+	reloadCheckInterval time.Duration
+}
+
+func newDBIPGeolocation(ctx context.Context, dataDir string, mmdbFile string) (*dbipGeolocation, error) {
+	if err := cleanupOldDatabases(path.Join(dataDir, dbipPattern), mmdbFile); err != nil {
+		return nil, fmt.Errorf("failed to remove old DB-IP databases: %v", err)
+	}
+
+	mmdbPath := path.Join(dataDir, mmdbFile)
+	db, err := newReloadableDB(mmdbPath, openDB, closeMaxMindReader)
+	if err != nil {
+		return nil, err
+	}
+
+	geo := &dbipGeolocation{
+		db:                  db,
+		reloadCheckInterval: 300 * time.Second,
+		stopCh:              make(chan struct{}),
+	}
+
+	go geo.reloader(ctx)
+
+	return geo, nil
+}
+
+func (gl *dbipGeolocation) Lookup(ip net.IP) (*Record, error) {
+	gl.mux.RLock()
+	defer gl.mux.RUnlock()
+
+	var record Record
+	if err := gl.db.db.Lookup(ip, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// GetAllCountries is not supported by the DB-IP provider: unlike MaxMind,
+// DB-IP's city mmdb has no geonames SQLite companion to enumerate from.
+func (gl *dbipGeolocation) GetAllCountries() ([]Country, error) {
+	return nil, fmt.Errorf("GetAllCountries is not supported by the dbip provider")
+}
+
+// GetCitiesByCountry is not supported by the DB-IP provider, see GetAllCountries.
+func (gl *dbipGeolocation) GetCitiesByCountry(countryISOCode string) ([]City, error) {
+	return nil, fmt.Errorf("GetCitiesByCountry is not supported by the dbip provider")
+}
+
+// LookupASN is not supported by the DB-IP provider: ASN/anonymous-proxy
+// enrichment is only wired up for the MaxMind provider.
+func (gl *dbipGeolocation) LookupASN(ip net.IP) (uint, string, error) {
+	return 0, "", fmt.Errorf("LookupASN is not supported by the dbip provider")
+}
+
+func (gl *dbipGeolocation) Stop() error {
+	close(gl.stopCh)
+	if gl.db != nil {
+		return gl.db.close()
+	}
+	return nil
+}
+
+func (gl *dbipGeolocation) reloader(ctx context.Context) {
+	watchReload(gl.stopCh, gl.reloadCheckInterval, func() {
+		if err := gl.db.reloadIfChanged(ctx, &gl.mux); err != nil {
+			log.WithContext(ctx).Errorf("dbip db reload failed: %s", err)
+		}
+	})
+}
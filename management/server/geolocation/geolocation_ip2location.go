@@ -0,0 +1,116 @@
+package geolocation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/ip2location/ip2location-go/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ip2locationPattern = "IP2LOCATION-DB_*.BIN"
+)
+
+// ip2locationGeolocation is an IP2Location BIN backed Geolocation
+// implementation. It's a drop-in alternative to the MaxMind provider for
+// operators who prefer IP2Location's licensing terms.
+type ip2locationGeolocation struct {
+	mux                 sync.RWMutex
+	db                  *reloadableDB[*ip2location.DB]
+	stopCh              chan struct{}
+	reloadCheckInterval time.Duration
+}
+
+func openIP2LocationDB(dbPath string) (*ip2location.DB, error) {
+	db, err := ip2location.OpenDB(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("%v could not be opened: %w", dbPath, err)
+	}
+	return db, nil
+}
+
+func closeIP2LocationDB(db *ip2location.DB) error {
+	db.Close()
+	return nil
+}
+
+func newIP2LocationGeolocation(ctx context.Context, dataDir string, dbFile string) (*ip2locationGeolocation, error) {
+	if exists, err := fileExists(path.Join(dataDir, dbFile)); !exists {
+		return nil, fmt.Errorf("failed to load IP2Location database: %v", err)
+	}
+
+	if err := cleanupOldDatabases(path.Join(dataDir, ip2locationPattern), dbFile); err != nil {
+		return nil, fmt.Errorf("failed to remove old IP2Location databases: %v", err)
+	}
+
+	dbPath := path.Join(dataDir, dbFile)
+	db, err := newReloadableDB(dbPath, openIP2LocationDB, closeIP2LocationDB)
+	if err != nil {
+		return nil, err
+	}
+
+	geo := &ip2locationGeolocation{
+		db:                  db,
+		reloadCheckInterval: 300 * time.Second,
+		stopCh:              make(chan struct{}),
+	}
+
+	go geo.reloader(ctx)
+
+	return geo, nil
+}
+
+func (gl *ip2locationGeolocation) Lookup(ip net.IP) (*Record, error) {
+	gl.mux.RLock()
+	defer gl.mux.RUnlock()
+
+	result, err := gl.db.db.Get_all(ip.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var record Record
+	record.Country.ISOCode = result.Country_short
+	record.City.Names.En = result.City
+
+	return &record, nil
+}
+
+// GetAllCountries is not supported by the IP2Location provider: the BIN
+// lookup database has no enumerable country/city table like the geonames
+// SQLite companion used by MaxMind.
+func (gl *ip2locationGeolocation) GetAllCountries() ([]Country, error) {
+	return nil, fmt.Errorf("GetAllCountries is not supported by the ip2location provider")
+}
+
+// GetCitiesByCountry is not supported by the IP2Location provider, see GetAllCountries.
+func (gl *ip2locationGeolocation) GetCitiesByCountry(countryISOCode string) ([]City, error) {
+	return nil, fmt.Errorf("GetCitiesByCountry is not supported by the ip2location provider")
+}
+
+// LookupASN is not supported by the IP2Location provider: ASN/anonymous-proxy
+// enrichment is only wired up for the MaxMind provider.
+func (gl *ip2locationGeolocation) LookupASN(ip net.IP) (uint, string, error) {
+	return 0, "", fmt.Errorf("LookupASN is not supported by the ip2location provider")
+}
+
+func (gl *ip2locationGeolocation) Stop() error {
+	close(gl.stopCh)
+	if gl.db != nil {
+		return gl.db.close()
+	}
+	return nil
+}
+
+func (gl *ip2locationGeolocation) reloader(ctx context.Context) {
+	watchReload(gl.stopCh, gl.reloadCheckInterval, func() {
+		if err := gl.db.reloadIfChanged(ctx, &gl.mux); err != nil {
+			log.WithContext(ctx).Errorf("ip2location db reload failed: %s", err)
+		}
+	})
+}
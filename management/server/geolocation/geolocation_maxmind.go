@@ -0,0 +1,350 @@
+package geolocation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	mmdbPattern           = "GeoLite2-City-maxmind_*.mmdb"
+	geonamesdbPattern     = "GeoLite2-City-geonames_*.db"
+	oldMMDBFilename       = "GeoLite2-City.mmdb"
+	oldGeoNamesDBFilename = "geonames.db"
+	asnMmdbPattern        = "GeoLite2-ASN-maxmind_*.mmdb"
+	anonMmdbPattern       = "GeoLite2-Anonymous-IP-maxmind_*.mmdb"
+)
+
+// asnRecord is the subset of a GeoLite2-ASN (or equivalent) mmdb entry used
+// to enrich Record with ASN data.
+type asnRecord struct {
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// mmdbGeolocation is the MaxMind GeoLite2 backed Geolocation implementation.
+type mmdbGeolocation struct {
+	mmdbPath            string
+	mux                 sync.RWMutex
+	sha256sum           []byte
+	db                  *maxminddb.Reader
+	locationDB          *SqliteStore
+	stopCh              chan struct{}
+	reloadCheckInterval time.Duration
+
+	// asn/anon are nil when ASN/anonymous-IP enrichment wasn't configured,
+	// in which case Lookup simply skips populating the corresponding Record
+	// fields.
+	asn  *reloadableDB[*maxminddb.Reader]
+	anon *reloadableDB[*maxminddb.Reader]
+}
+
+func closeMaxMindReader(db *maxminddb.Reader) error {
+	return db.Close()
+}
+
+func newMaxMindGeolocation(ctx context.Context, dataDir string, mmdbFile string, geonamesdbFile string, asnMmdbFile string, anonMmdbFile string) (*mmdbGeolocation, error) {
+	if err := loadGeolocationDatabases(dataDir, mmdbFile, geonamesdbFile); err != nil {
+		return nil, fmt.Errorf("failed to load MaxMind databases: %v", err)
+	}
+
+	if err := cleanupMaxMindDatabases(dataDir, mmdbFile, geonamesdbFile); err != nil {
+		return nil, fmt.Errorf("failed to remove old MaxMind databases: %v", err)
+	}
+
+	mmdbPath := path.Join(dataDir, mmdbFile)
+	db, err := openDB(mmdbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sha256sum, err := calculateFileSHA256(mmdbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	locationDB, err := NewSqliteStore(ctx, dataDir, geonamesdbFile)
+	if err != nil {
+		return nil, err
+	}
+
+	geo := &mmdbGeolocation{
+		mmdbPath:            mmdbPath,
+		mux:                 sync.RWMutex{},
+		sha256sum:           sha256sum,
+		db:                  db,
+		locationDB:          locationDB,
+		reloadCheckInterval: 300 * time.Second, // TODO: make configurable
+		stopCh:              make(chan struct{}),
+	}
+
+	if asnMmdbFile != "" {
+		if err := cleanupOldDatabases(path.Join(dataDir, asnMmdbPattern), asnMmdbFile); err != nil {
+			return nil, fmt.Errorf("failed to remove old ASN databases: %v", err)
+		}
+
+		geo.asn, err = newReloadableDB(path.Join(dataDir, asnMmdbFile), openDB, closeMaxMindReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ASN database: %v", err)
+		}
+	}
+
+	if anonMmdbFile != "" {
+		if err := cleanupOldDatabases(path.Join(dataDir, anonMmdbPattern), anonMmdbFile); err != nil {
+			return nil, fmt.Errorf("failed to remove old anonymous-IP databases: %v", err)
+		}
+
+		geo.anon, err = newReloadableDB(path.Join(dataDir, anonMmdbFile), openDB, closeMaxMindReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load anonymous-IP database: %v", err)
+		}
+	}
+
+	go geo.reloader(ctx)
+
+	return geo, nil
+}
+
+func GetMaxMindFilenames(dataDir string, autoUpdate bool) (string, string) {
+	mmdbGlobPattern := path.Join(dataDir, mmdbPattern)
+	mmdbFilename, err := getDatabaseFilename(geoLiteCityTarGZURL, mmdbGlobPattern, autoUpdate)
+	if err != nil {
+		log.Warnf("Failed to get MaxMind database filename. Using old version, %s: %v", oldMMDBFilename, err)
+		mmdbFilename = oldMMDBFilename
+	}
+	geonamesdbGlobPattern := path.Join(dataDir, geonamesdbPattern)
+	geonamesdbFilename, err := getDatabaseFilename(geoLiteCityZipURL, geonamesdbGlobPattern, autoUpdate)
+	if err != nil {
+		log.Warnf("Failed to get GeoNames database filename. Using old version, %s: %v", oldGeoNamesDBFilename, err)
+		geonamesdbFilename = oldGeoNamesDBFilename
+	}
+
+	return mmdbFilename, geonamesdbFilename
+}
+
+func openDB(mmdbPath string) (*maxminddb.Reader, error) {
+	_, err := os.Stat(mmdbPath)
+
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%v does not exist", mmdbPath)
+	} else if err != nil {
+		return nil, err
+	}
+
+	db, err := maxminddb.Open(mmdbPath)
+	if err != nil {
+		return nil, fmt.Errorf("%v could not be opened: %w", mmdbPath, err)
+	}
+
+	return db, nil
+}
+
+func (gl *mmdbGeolocation) Lookup(ip net.IP) (*Record, error) {
+	gl.mux.RLock()
+	defer gl.mux.RUnlock()
+
+	var record Record
+	err := gl.db.Lookup(ip, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if gl.asn != nil {
+		var asn asnRecord
+		if err := gl.asn.db.Lookup(ip, &asn); err != nil {
+			log.Warnf("ASN lookup failed for %s: %v", ip, err)
+		} else {
+			record.ASN = asn.AutonomousSystemNumber
+			record.ASOrganization = asn.AutonomousSystemOrganization
+		}
+	}
+
+	if gl.anon != nil {
+		var anon struct {
+			IsAnonymousProxy    bool `maxminddb:"is_anonymous"`
+			IsSatelliteProvider bool `maxminddb:"is_satellite_provider"`
+			IsHostingProvider   bool `maxminddb:"is_hosting_provider"`
+		}
+		if err := gl.anon.db.Lookup(ip, &anon); err != nil {
+			log.Warnf("anonymous-IP lookup failed for %s: %v", ip, err)
+		} else {
+			record.IsAnonymousProxy = anon.IsAnonymousProxy
+			record.IsSatelliteProvider = anon.IsSatelliteProvider
+			record.IsHostingProvider = anon.IsHostingProvider
+		}
+	}
+
+	return &record, nil
+}
+
+// LookupASN resolves only the ASN/organization for ip, for callers that don't
+// need the full Record. It requires the provider to have been configured
+// with an ASN mmdb.
+func (gl *mmdbGeolocation) LookupASN(ip net.IP) (uint, string, error) {
+	gl.mux.RLock()
+	defer gl.mux.RUnlock()
+
+	if gl.asn == nil {
+		return 0, "", fmt.Errorf("ASN database is not configured")
+	}
+
+	var asn asnRecord
+	if err := gl.asn.db.Lookup(ip, &asn); err != nil {
+		return 0, "", err
+	}
+
+	return asn.AutonomousSystemNumber, asn.AutonomousSystemOrganization, nil
+}
+
+// GetAllCountries retrieves a list of all countries.
+func (gl *mmdbGeolocation) GetAllCountries() ([]Country, error) {
+	allCountries, err := gl.locationDB.GetAllCountries()
+	if err != nil {
+		return nil, err
+	}
+
+	countries := make([]Country, 0)
+	for _, country := range allCountries {
+		if country.CountryName != "" {
+			countries = append(countries, country)
+		}
+	}
+	return countries, nil
+}
+
+// GetCitiesByCountry retrieves a list of cities in a specific country based on the country's ISO code.
+func (gl *mmdbGeolocation) GetCitiesByCountry(countryISOCode string) ([]City, error) {
+	allCities, err := gl.locationDB.GetCitiesByCountry(countryISOCode)
+	if err != nil {
+		return nil, err
+	}
+
+	cities := make([]City, 0)
+	for _, city := range allCities {
+		if city.CityName != "" {
+			cities = append(cities, city)
+		}
+	}
+	return cities, nil
+}
+
+func (gl *mmdbGeolocation) Stop() error {
+	close(gl.stopCh)
+	if gl.db != nil {
+		if err := gl.db.Close(); err != nil {
+			return err
+		}
+	}
+	if gl.asn != nil {
+		if err := gl.asn.close(); err != nil {
+			return err
+		}
+	}
+	if gl.anon != nil {
+		if err := gl.anon.close(); err != nil {
+			return err
+		}
+	}
+	if gl.locationDB != nil {
+		if err := gl.locationDB.close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gl *mmdbGeolocation) reloader(ctx context.Context) {
+	watchReload(gl.stopCh, gl.reloadCheckInterval, func() {
+		if err := gl.locationDB.reload(ctx); err != nil {
+			log.WithContext(ctx).Errorf("geonames db reload failed: %s", err)
+		}
+
+		newSha256sum1, err := calculateFileSHA256(gl.mmdbPath)
+		if err != nil {
+			log.WithContext(ctx).Errorf("failed to calculate sha256 sum for '%s': %s", gl.mmdbPath, err)
+			return
+		}
+		if !bytes.Equal(gl.sha256sum, newSha256sum1) {
+			// we check sum twice just to avoid possible case when we reload during update of the file
+			// considering the frequency of file update (few times a week) checking sum twice should be enough
+			time.Sleep(50 * time.Millisecond)
+			newSha256sum2, err := calculateFileSHA256(gl.mmdbPath)
+			if err != nil {
+				log.WithContext(ctx).Errorf("failed to calculate sha256 sum for '%s': %s", gl.mmdbPath, err)
+				return
+			}
+			if !bytes.Equal(newSha256sum1, newSha256sum2) {
+				log.WithContext(ctx).Errorf("sha256 sum changed during reloading of '%s'", gl.mmdbPath)
+				return
+			}
+			if err := gl.reload(ctx, newSha256sum2); err != nil {
+				log.WithContext(ctx).Errorf("mmdb reload failed: %s", err)
+			}
+		} else {
+			log.WithContext(ctx).Tracef("No changes in '%s', no need to reload. Next check is in %.0f seconds.",
+				gl.mmdbPath, gl.reloadCheckInterval.Seconds())
+		}
+
+		if gl.asn != nil {
+			if err := gl.asn.reloadIfChanged(ctx, &gl.mux); err != nil {
+				log.WithContext(ctx).Errorf("ASN mmdb reload failed: %s", err)
+			}
+		}
+
+		if gl.anon != nil {
+			if err := gl.anon.reloadIfChanged(ctx, &gl.mux); err != nil {
+				log.WithContext(ctx).Errorf("anonymous-IP mmdb reload failed: %s", err)
+			}
+		}
+	})
+}
+
+func (gl *mmdbGeolocation) reload(ctx context.Context, newSha256sum []byte) error {
+	gl.mux.Lock()
+	defer gl.mux.Unlock()
+
+	log.WithContext(ctx).Infof("Reloading '%s'", gl.mmdbPath)
+
+	err := gl.db.Close()
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(gl.mmdbPath)
+	if err != nil {
+		return err
+	}
+
+	gl.db = db
+	gl.sha256sum = newSha256sum
+
+	log.WithContext(ctx).Infof("Successfully reloaded '%s'", gl.mmdbPath)
+
+	return nil
+}
+
+func cleanupMaxMindDatabases(dataDir string, mmdbFile string, geonamesdbFile string) error {
+	for _, file := range []string{mmdbFile, geonamesdbFile} {
+		switch file {
+		case mmdbFile:
+			pattern := path.Join(dataDir, mmdbPattern)
+			if err := cleanupOldDatabases(pattern, file); err != nil {
+				return err
+			}
+		case geonamesdbFile:
+			pattern := path.Join(dataDir, geonamesdbPattern)
+			if err := cleanupOldDatabases(pattern, file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package geolocation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadableDB bundles a database handle of type T with sha256-based change
+// detection for the file it was opened from, plus the open/close funcs
+// needed to swap it in place. It factors out the watch-and-reload scaffolding
+// shared by the MaxMind (ASN/anonymous-IP), IP2Location and DB-IP providers,
+// which otherwise all reimplement the same "hash file, reopen if changed,
+// close the old handle" loop around their own reader type.
+type reloadableDB[T any] struct {
+	path      string
+	sha256sum []byte
+	db        T
+	open      func(path string) (T, error)
+	closeDB   func(T) error
+}
+
+// newReloadableDB opens path via open and records its initial checksum.
+func newReloadableDB[T any](path string, open func(string) (T, error), closeDB func(T) error) (*reloadableDB[T], error) {
+	db, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sha256sum, err := calculateFileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reloadableDB[T]{
+		path:      path,
+		sha256sum: sha256sum,
+		db:        db,
+		open:      open,
+		closeDB:   closeDB,
+	}, nil
+}
+
+// reloadIfChanged re-opens the database if path's contents changed since the
+// last recorded checksum, swapping the handle in place behind mux and
+// closing the previous one. mux also guards concurrent readers of r.db, so
+// callers should take mux.RLock around any use of r.db.
+func (r *reloadableDB[T]) reloadIfChanged(ctx context.Context, mux *sync.RWMutex) error {
+	newSha256sum, err := calculateFileSHA256(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to calculate sha256 sum for '%s': %w", r.path, err)
+	}
+
+	mux.RLock()
+	changed := !bytes.Equal(r.sha256sum, newSha256sum)
+	mux.RUnlock()
+	if !changed {
+		log.WithContext(ctx).Tracef("No changes in '%s', no need to reload.", r.path)
+		return nil
+	}
+
+	newDB, err := r.open(r.path)
+	if err != nil {
+		return err
+	}
+
+	mux.Lock()
+	oldDB := r.db
+	r.db = newDB
+	r.sha256sum = newSha256sum
+	mux.Unlock()
+
+	if err := r.closeDB(oldDB); err != nil {
+		log.WithContext(ctx).Errorf("failed to close previous '%s': %s", r.path, err)
+	}
+
+	log.WithContext(ctx).Infof("Successfully reloaded '%s'", r.path)
+
+	return nil
+}
+
+func (r *reloadableDB[T]) close() error {
+	return r.closeDB(r.db)
+}
+
+// watchReload calls fn on every tick of interval until stopCh is closed. It's
+// the scheduling half of the reload loop shared by the geolocation providers;
+// fn is responsible for deciding whether anything actually needs reloading.
+func watchReload(stopCh <-chan struct{}, interval time.Duration, fn func()) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(interval):
+			fn()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package geolocation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestReloadableDBReloadIfChanged drives reloadIfChanged through a file swap,
+// the same way the maxmind/ip2location/dbip providers' reloader loops do,
+// using a trivial string-backed "database" instead of a real mmdb/BIN file.
+func TestReloadableDBReloadIfChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.txt")
+	if err := os.WriteFile(path, []byte("v1"), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+
+	var opens, closes int
+	open := func(p string) (string, error) {
+		opens++
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	closeDB := func(string) error {
+		closes++
+		return nil
+	}
+
+	db, err := newReloadableDB(path, open, closeDB)
+	if err != nil {
+		t.Fatalf("newReloadableDB: %v", err)
+	}
+	if db.db != "v1" {
+		t.Fatalf("db.db = %q, want %q", db.db, "v1")
+	}
+	if opens != 1 {
+		t.Fatalf("opens = %d, want 1", opens)
+	}
+
+	var mux sync.RWMutex
+
+	// Reloading with no change on disk must not reopen the file.
+	if err := db.reloadIfChanged(context.Background(), &mux); err != nil {
+		t.Fatalf("reloadIfChanged (unchanged): %v", err)
+	}
+	if opens != 1 {
+		t.Fatalf("opens after unchanged reload = %d, want 1", opens)
+	}
+
+	if err := os.WriteFile(path, []byte("v2"), 0o600); err != nil {
+		t.Fatalf("overwrite %s: %v", path, err)
+	}
+
+	if err := db.reloadIfChanged(context.Background(), &mux); err != nil {
+		t.Fatalf("reloadIfChanged (changed): %v", err)
+	}
+
+	mux.RLock()
+	got := db.db
+	mux.RUnlock()
+
+	if got != "v2" {
+		t.Fatalf("db.db after reload = %q, want %q", got, "v2")
+	}
+	if opens != 2 {
+		t.Fatalf("opens after changed reload = %d, want 2", opens)
+	}
+	if closes != 1 {
+		t.Fatalf("closes after changed reload = %d, want 1 (old handle closed)", closes)
+	}
+}
@@ -0,0 +1,64 @@
+package policy
+
+import (
+	"sync"
+	"time"
+)
+
+// lookupCache is a small TTL cache for resolved ipInfo, keyed by IP string.
+// It exists to dedupe repeated geolocation.Geolocation lookups when the same
+// IP is evaluated more than once in quick succession, e.g. once per unary
+// call and once per stream call for the same peer.
+type lookupCache struct {
+	ttl time.Duration
+
+	mux     sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	info    ipInfo
+	expires time.Time
+}
+
+func newLookupCache(ttl time.Duration) *lookupCache {
+	return &lookupCache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *lookupCache) get(key string) (ipInfo, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return ipInfo{}, false
+	}
+	return entry.info, true
+}
+
+func (c *lookupCache) set(key string, info ipInfo) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	// Opportunistically drop expired entries so the cache doesn't grow
+	// unbounded under a steady stream of distinct short-lived peers.
+	if len(c.entries) > 4096 {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+
+	c.entries[key] = cacheEntry{info: info, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *lookupCache) clear() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.entries = make(map[string]cacheEntry)
+}
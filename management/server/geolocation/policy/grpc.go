@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"context"
+	"net"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Interceptors builds gRPC server interceptors that evaluate the peer's IP
+// against the Engine's rules and reject the call with codes.PermissionDenied
+// when denied. allow/deny decisions are counted per matched rule ID through
+// counter, so operators can alert on denies spiking for a given rule.
+func (e *Engine) Interceptors(meter metric.Meter) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor, error) {
+	counter, err := meter.Int64Counter(
+		"geolocation_policy_decisions_total",
+		metric.WithDescription("Number of geo/ASN/CIDR policy decisions, by decision and matched rule ID"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unary := func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := e.authorize(ctx, counter); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+
+	stream := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := e.authorize(ss.Context(), counter); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+
+	return unary, stream, nil
+}
+
+func (e *Engine) authorize(ctx context.Context, counter metric.Int64Counter) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		// No peer info to evaluate against; fail open rather than break
+		// transports (e.g. bufconn in tests) that don't set one.
+		return nil
+	}
+
+	ip := peerIP(p.Addr)
+	if ip == nil {
+		return nil
+	}
+
+	decision, matched, err := e.Evaluate(ip)
+	if err != nil {
+		return status.Errorf(codes.Internal, "geo policy evaluation failed: %v", err)
+	}
+
+	ruleID := matched.ID
+	if ruleID == "" {
+		ruleID = "default"
+	}
+	counter.Add(ctx, 1,
+		metric.WithAttributes(
+			attribute.String("decision", decision.String()),
+			attribute.String("rule_id", ruleID),
+		),
+	)
+
+	if decision == DecisionDeny {
+		return status.Errorf(codes.PermissionDenied, "peer %s denied by geo policy rule %q", ip, ruleID)
+	}
+
+	return nil
+}
+
+func peerIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return nil
+		}
+		return net.ParseIP(host)
+	}
+}
@@ -0,0 +1,403 @@
+// Package policy evaluates geo/ASN/CIDR based access-control rules against
+// an IP address, on top of the lookups exposed by the geolocation package.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/netbirdio/netbird/management/server/geolocation"
+)
+
+// Decision is the outcome of evaluating an IP against the configured rules.
+type Decision int
+
+const (
+	// DecisionAllow means the IP is permitted.
+	DecisionAllow Decision = iota
+	// DecisionDeny means the IP is rejected.
+	DecisionDeny
+)
+
+func (d Decision) String() string {
+	if d == DecisionDeny {
+		return "deny"
+	}
+	return "allow"
+}
+
+// Rule is a single allow/deny entry. Any combination of Country, Continent,
+// ASN and CIDR may be set; when more than one is set, all of them must match
+// for the rule to apply (e.g. {country: US, asn: 64500} only matches IPs
+// that are both geolocated to the US and announced by ASN 64500).
+type Rule struct {
+	ID        string `yaml:"id" json:"id"`
+	Country   string `yaml:"country,omitempty" json:"country,omitempty"`
+	Continent string `yaml:"continent,omitempty" json:"continent,omitempty"`
+	ASN       uint   `yaml:"asn,omitempty" json:"asn,omitempty"`
+	CIDR      string `yaml:"cidr,omitempty" json:"cidr,omitempty"`
+}
+
+// MatchedRule identifies which rule (and which list it came from) produced a
+// Decision.
+type MatchedRule struct {
+	Rule
+	List string // "allow" or "deny"
+}
+
+type rulesDocument struct {
+	Allow []Rule `yaml:"allow" json:"allow"`
+	Deny  []Rule `yaml:"deny" json:"deny"`
+}
+
+// Engine evaluates IPs against a hot-reloadable rules document.
+type Engine struct {
+	path string
+	geo  geolocation.Geolocation
+
+	mux       sync.RWMutex
+	sha256sum []byte
+
+	allowCIDR *cidrTrie
+	denyCIDR  *cidrTrie
+
+	allowCountry   map[string]Rule
+	denyCountry    map[string]Rule
+	allowContinent map[string]Rule
+	denyContinent  map[string]Rule
+	allowASN       map[uint]Rule
+	denyASN        map[uint]Rule
+
+	// allowMixed/denyMixed hold rules with two or more discriminator fields
+	// set (e.g. country+asn); those can't be indexed under a single
+	// exclusive map like the rest, so they're matched with a linear scan
+	// that requires every set field to match.
+	allowMixed []Rule
+	denyMixed  []Rule
+
+	// hasAllowRules controls the default decision: once any allow rule is
+	// configured, an IP that matches nothing is denied by default, mirroring
+	// typical allow-list firewall semantics. With no allow rules at all
+	// (deny-list only, or no rules), unmatched IPs are allowed.
+	hasAllowRules bool
+
+	cache *lookupCache
+
+	reloadCheckInterval time.Duration
+	stopCh              chan struct{}
+}
+
+// NewEngine loads rulesFile and starts watching it for changes. geo is used
+// to resolve country/continent/ASN for an IP; it may be nil if the rules
+// document only contains CIDR rules.
+func NewEngine(ctx context.Context, rulesFile string, geo geolocation.Geolocation) (*Engine, error) {
+	e := &Engine{
+		path:                rulesFile,
+		geo:                 geo,
+		cache:               newLookupCache(2 * time.Second),
+		reloadCheckInterval: 30 * time.Second,
+		stopCh:              make(chan struct{}),
+	}
+
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+
+	go e.reloader(ctx)
+
+	return e, nil
+}
+
+// Evaluate resolves ip against the loaded rules. CIDR matches are checked
+// first: the longest prefix wins across the deny/allow tries, and deny wins
+// when both prefixes are equally long. Otherwise the mixed-field, ASN,
+// country and continent deny rules are checked, in that order, before any
+// allow rule of those kinds, so among them a deny always wins over an allow,
+// whatever their relative specificity.
+func (e *Engine) Evaluate(ip net.IP) (Decision, MatchedRule, error) {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+
+	denyRule, denyOK := e.denyCIDR.longestMatch(ip)
+	allowRule, allowOK := e.allowCIDR.longestMatch(ip)
+	if denyOK && allowOK {
+		_, denyNet, _ := net.ParseCIDR(denyRule.CIDR)
+		_, allowNet, _ := net.ParseCIDR(allowRule.CIDR)
+		denyOnes, _ := denyNet.Mask.Size()
+		allowOnes, _ := allowNet.Mask.Size()
+		if allowOnes > denyOnes {
+			denyOK = false
+		}
+	}
+	if denyOK {
+		return DecisionDeny, MatchedRule{Rule: denyRule, List: "deny"}, nil
+	}
+	if allowOK {
+		return DecisionAllow, MatchedRule{Rule: allowRule, List: "allow"}, nil
+	}
+
+	if len(e.allowASN) > 0 || len(e.denyASN) > 0 ||
+		len(e.allowCountry) > 0 || len(e.denyCountry) > 0 ||
+		len(e.allowContinent) > 0 || len(e.denyContinent) > 0 ||
+		len(e.allowMixed) > 0 || len(e.denyMixed) > 0 {
+		info, err := e.resolve(ip)
+		if err != nil {
+			return DecisionAllow, MatchedRule{}, fmt.Errorf("resolve %s: %w", ip, err)
+		}
+
+		if rule, ok := matchMixed(e.denyMixed, ip, info); ok {
+			return DecisionDeny, MatchedRule{Rule: rule, List: "deny"}, nil
+		}
+		if rule, ok := e.denyASN[info.asn]; ok && info.asn != 0 {
+			return DecisionDeny, MatchedRule{Rule: rule, List: "deny"}, nil
+		}
+		if rule, ok := e.denyCountry[info.country]; ok && info.country != "" {
+			return DecisionDeny, MatchedRule{Rule: rule, List: "deny"}, nil
+		}
+		if rule, ok := e.denyContinent[info.continent]; ok && info.continent != "" {
+			return DecisionDeny, MatchedRule{Rule: rule, List: "deny"}, nil
+		}
+
+		if rule, ok := matchMixed(e.allowMixed, ip, info); ok {
+			return DecisionAllow, MatchedRule{Rule: rule, List: "allow"}, nil
+		}
+		if rule, ok := e.allowASN[info.asn]; ok && info.asn != 0 {
+			return DecisionAllow, MatchedRule{Rule: rule, List: "allow"}, nil
+		}
+		if rule, ok := e.allowCountry[info.country]; ok && info.country != "" {
+			return DecisionAllow, MatchedRule{Rule: rule, List: "allow"}, nil
+		}
+		if rule, ok := e.allowContinent[info.continent]; ok && info.continent != "" {
+			return DecisionAllow, MatchedRule{Rule: rule, List: "allow"}, nil
+		}
+	}
+
+	if e.hasAllowRules {
+		return DecisionDeny, MatchedRule{}, nil
+	}
+	return DecisionAllow, MatchedRule{}, nil
+}
+
+// matchMixed returns the first rule in rules whose every set discriminator
+// field matches ip/info. rules only ever holds entries with two or more
+// fields set (see load), so this is the slow path used exclusively for
+// those; single-field rules are matched via the exclusive maps/trie above.
+func matchMixed(rules []Rule, ip net.IP, info ipInfo) (Rule, bool) {
+	for _, r := range rules {
+		if ruleMatches(r, ip, info) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// ruleMatches reports whether every discriminator field set on r matches ip
+// and info; fields left unset on r impose no constraint.
+func ruleMatches(r Rule, ip net.IP, info ipInfo) bool {
+	if r.Country != "" && strings.ToUpper(r.Country) != info.country {
+		return false
+	}
+	if r.Continent != "" && strings.ToUpper(r.Continent) != info.continent {
+		return false
+	}
+	if r.ASN != 0 && r.ASN != info.asn {
+		return false
+	}
+	if r.CIDR != "" {
+		_, ipNet, err := net.ParseCIDR(r.CIDR)
+		if err != nil || !ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+type ipInfo struct {
+	country   string
+	continent string
+	asn       uint
+}
+
+// resolve looks up country/continent/ASN for ip, reusing a short-lived cache
+// entry so that evaluating the same IP more than once in quick succession
+// (e.g. several unary or stream calls from the same peer)
+// doesn't repeat the geolocation.Geolocation lookups.
+func (e *Engine) resolve(ip net.IP) (ipInfo, error) {
+	key := ip.String()
+	if info, ok := e.cache.get(key); ok {
+		return info, nil
+	}
+
+	if e.geo == nil {
+		return ipInfo{}, fmt.Errorf("no geolocation backend configured")
+	}
+
+	var info ipInfo
+	record, err := e.geo.Lookup(ip)
+	if err != nil {
+		return ipInfo{}, err
+	}
+	info.country = record.Country.ISOCode
+	info.continent = record.Continent.Code
+	info.asn = record.ASN
+
+	if info.asn == 0 {
+		if asn, _, err := e.geo.LookupASN(ip); err == nil {
+			info.asn = asn
+		}
+	}
+
+	e.cache.set(key, info)
+
+	return info, nil
+}
+
+func (e *Engine) load() error {
+	raw, err := os.ReadFile(e.path)
+	if err != nil {
+		return fmt.Errorf("read rules file: %w", err)
+	}
+
+	// YAML is a superset of JSON, so a single unmarshal call handles both the
+	// .yaml/.yml and .json cases the file may be named.
+	var doc rulesDocument
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("parse rules file: %w", err)
+	}
+
+	allowCIDR := newCIDRTrie()
+	denyCIDR := newCIDRTrie()
+	allowCountry := map[string]Rule{}
+	denyCountry := map[string]Rule{}
+	allowContinent := map[string]Rule{}
+	denyContinent := map[string]Rule{}
+	allowASN := map[uint]Rule{}
+	denyASN := map[uint]Rule{}
+
+	// index files each rule under the exclusive maps/trie when it has
+	// exactly one discriminator field set (the common case, letting
+	// Evaluate do an O(1)/O(prefix-length) lookup), or appends it to mixed
+	// when two or more are set, since those require checking every field
+	// against a candidate rather than a single key lookup.
+	index := func(rules []Rule, cidrTrie *cidrTrie, byCountry, byContinent map[string]Rule, byASN map[uint]Rule) ([]Rule, error) {
+		var mixed []Rule
+		for _, r := range rules {
+			set := 0
+			if r.Country != "" {
+				set++
+			}
+			if r.Continent != "" {
+				set++
+			}
+			if r.ASN != 0 {
+				set++
+			}
+			if r.CIDR != "" {
+				set++
+			}
+
+			switch {
+			case set == 0:
+				return nil, fmt.Errorf("rule %q has no country, continent, asn or cidr set", r.ID)
+			case set > 1:
+				mixed = append(mixed, r)
+			case r.CIDR != "":
+				if err := cidrTrie.insert(r.CIDR, r); err != nil {
+					return nil, err
+				}
+			case r.ASN != 0:
+				byASN[r.ASN] = r
+			case r.Country != "":
+				byCountry[strings.ToUpper(r.Country)] = r
+			case r.Continent != "":
+				byContinent[strings.ToUpper(r.Continent)] = r
+			}
+		}
+		return mixed, nil
+	}
+
+	allowMixed, err := index(doc.Allow, allowCIDR, allowCountry, allowContinent, allowASN)
+	if err != nil {
+		return fmt.Errorf("invalid allow rule: %w", err)
+	}
+	denyMixed, err := index(doc.Deny, denyCIDR, denyCountry, denyContinent, denyASN)
+	if err != nil {
+		return fmt.Errorf("invalid deny rule: %w", err)
+	}
+
+	needsGeo := len(allowCountry) > 0 || len(denyCountry) > 0 ||
+		len(allowContinent) > 0 || len(denyContinent) > 0 ||
+		len(allowASN) > 0 || len(denyASN) > 0 ||
+		len(allowMixed) > 0 || len(denyMixed) > 0
+	if needsGeo && e.geo == nil {
+		return fmt.Errorf("rules file %q has country, continent or asn rules but no geolocation backend is configured", e.path)
+	}
+
+	sum := sha256.Sum256(raw)
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.allowCIDR = allowCIDR
+	e.denyCIDR = denyCIDR
+	e.allowCountry = allowCountry
+	e.denyCountry = denyCountry
+	e.allowContinent = allowContinent
+	e.denyContinent = denyContinent
+	e.allowASN = allowASN
+	e.denyASN = denyASN
+	e.allowMixed = allowMixed
+	e.denyMixed = denyMixed
+	e.hasAllowRules = len(doc.Allow) > 0
+	e.sha256sum = sum[:]
+	e.cache.clear()
+
+	return nil
+}
+
+func (e *Engine) reloader(ctx context.Context) {
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-time.After(e.reloadCheckInterval):
+			raw, err := os.ReadFile(e.path)
+			if err != nil {
+				log.WithContext(ctx).Errorf("failed to read policy rules file '%s': %s", e.path, err)
+				continue
+			}
+			sum := sha256.Sum256(raw)
+
+			e.mux.RLock()
+			changed := !bytes.Equal(e.sha256sum, sum[:])
+			e.mux.RUnlock()
+
+			if !changed {
+				log.WithContext(ctx).Tracef("No changes in '%s', no need to reload.", e.path)
+				continue
+			}
+
+			if err := e.load(); err != nil {
+				log.WithContext(ctx).Errorf("failed to reload policy rules file '%s': %s", e.path, err)
+				continue
+			}
+			log.WithContext(ctx).Infof("Successfully reloaded policy rules file '%s'", e.path)
+		}
+	}
+}
+
+// Stop stops the reload loop.
+func (e *Engine) Stop() {
+	close(e.stopCh)
+}
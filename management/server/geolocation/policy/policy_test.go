@@ -0,0 +1,202 @@
+package policy
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/netbirdio/netbird/management/server/geolocation"
+)
+
+// fakeGeolocation is a minimal geolocation.Geolocation backed by a static
+// map[ip]geolocation.Record, for tests that need Engine.resolve to return
+// country/continent/ASN without a real mmdb.
+type fakeGeolocation struct {
+	records map[string]geolocation.Record
+}
+
+func (f *fakeGeolocation) Lookup(ip net.IP) (*geolocation.Record, error) {
+	record, ok := f.records[ip.String()]
+	if !ok {
+		return &geolocation.Record{}, nil
+	}
+	return &record, nil
+}
+
+func (f *fakeGeolocation) GetAllCountries() ([]geolocation.Country, error) { return nil, nil }
+
+func (f *fakeGeolocation) GetCitiesByCountry(string) ([]geolocation.City, error) { return nil, nil }
+
+func (f *fakeGeolocation) LookupASN(ip net.IP) (uint, string, error) {
+	record, ok := f.records[ip.String()]
+	if !ok {
+		return 0, "", nil
+	}
+	return record.ASN, record.ASOrganization, nil
+}
+
+func (f *fakeGeolocation) Stop() error { return nil }
+
+// newRecord builds a geolocation.Record with the given country/continent ISO
+// codes and ASN, saving callers from repeating Record's nested anonymous
+// struct literals.
+func newRecord(country, continent string, asn uint) geolocation.Record {
+	var r geolocation.Record
+	r.Country.ISOCode = country
+	r.Continent.Code = continent
+	r.ASN = asn
+	return r
+}
+
+// newTestEngine writes doc as the rules file content and loads an Engine
+// against it, bypassing NewEngine's reload goroutine since tests drive load
+// reloads explicitly (or not at all).
+func newTestEngine(t *testing.T, doc string, geo geolocation.Geolocation) *Engine {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatalf("write rules file: %v", err)
+	}
+
+	e := &Engine{path: path, geo: geo, cache: newLookupCache(0)}
+	if err := e.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return e
+}
+
+func TestEngineEvaluateCIDROverridesCountry(t *testing.T) {
+	geo := &fakeGeolocation{records: map[string]geolocation.Record{
+		"10.0.0.1": newRecord("US", "", 0),
+	}}
+
+	e := newTestEngine(t, `
+allow:
+  - id: allow-us
+    country: US
+deny:
+  - id: deny-specific-host
+    cidr: 10.0.0.1/32
+`, geo)
+
+	decision, matched, err := e.Evaluate(net.ParseIP("10.0.0.1"))
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision != DecisionDeny || matched.ID != "deny-specific-host" {
+		t.Fatalf("Evaluate = (%v, %q), want (deny, deny-specific-host)", decision, matched.ID)
+	}
+}
+
+func TestEngineEvaluateDenyOverridesAllow(t *testing.T) {
+	geo := &fakeGeolocation{records: map[string]geolocation.Record{
+		"1.2.3.4": newRecord("", "", 64500),
+	}}
+
+	e := newTestEngine(t, `
+allow:
+  - id: allow-asn
+    asn: 64500
+deny:
+  - id: deny-asn
+    asn: 64500
+`, geo)
+
+	decision, matched, err := e.Evaluate(net.ParseIP("1.2.3.4"))
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision != DecisionDeny || matched.ID != "deny-asn" {
+		t.Fatalf("Evaluate = (%v, %q), want (deny, deny-asn)", decision, matched.ID)
+	}
+}
+
+func TestEngineEvaluateMixedFieldRuleRequiresAllFields(t *testing.T) {
+	geo := &fakeGeolocation{records: map[string]geolocation.Record{
+		// Same ASN as the rule, but wrong country: must not match.
+		"1.1.1.1": newRecord("DE", "", 64500),
+		// Both fields match: must match.
+		"2.2.2.2": newRecord("US", "", 64500),
+	}}
+
+	e := newTestEngine(t, `
+deny:
+  - id: deny-us-asn
+    country: US
+    asn: 64500
+`, geo)
+
+	decision, _, err := e.Evaluate(net.ParseIP("1.1.1.1"))
+	if err != nil {
+		t.Fatalf("Evaluate(1.1.1.1): %v", err)
+	}
+	if decision != DecisionAllow {
+		t.Fatalf("Evaluate(1.1.1.1) = %v, want allow (country mismatch should prevent the mixed rule from firing)", decision)
+	}
+
+	decision, matched, err := e.Evaluate(net.ParseIP("2.2.2.2"))
+	if err != nil {
+		t.Fatalf("Evaluate(2.2.2.2): %v", err)
+	}
+	if decision != DecisionDeny || matched.ID != "deny-us-asn" {
+		t.Fatalf("Evaluate(2.2.2.2) = (%v, %q), want (deny, deny-us-asn)", decision, matched.ID)
+	}
+}
+
+func TestEngineEvaluateDefaultDecision(t *testing.T) {
+	// No allow rules at all: unmatched IPs are allowed by default.
+	denyOnly := newTestEngine(t, `
+deny:
+  - id: deny-cidr
+    cidr: 10.0.0.0/8
+`, nil)
+
+	decision, _, err := denyOnly.Evaluate(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision != DecisionAllow {
+		t.Fatalf("deny-only ruleset: unmatched IP = %v, want allow", decision)
+	}
+
+	// Once any allow rule exists, unmatched IPs flip to deny-by-default.
+	withAllow := newTestEngine(t, `
+allow:
+  - id: allow-cidr
+    cidr: 10.0.0.0/8
+`, nil)
+
+	decision, _, err = withAllow.Evaluate(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision != DecisionDeny {
+		t.Fatalf("allow-list-present ruleset: unmatched IP = %v, want deny", decision)
+	}
+
+	decision, _, err = withAllow.Evaluate(net.ParseIP("10.0.0.1"))
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if decision != DecisionAllow {
+		t.Fatalf("allow-list-present ruleset: matched IP = %v, want allow", decision)
+	}
+}
+
+func TestNewEngineRequiresGeoBackendForNonCIDRRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(`
+allow:
+  - id: allow-us
+    country: US
+`), 0o600); err != nil {
+		t.Fatalf("write rules file: %v", err)
+	}
+
+	e := &Engine{path: path, cache: newLookupCache(0)}
+	if err := e.load(); err == nil {
+		t.Fatal("load() with a country rule and no geo backend returned nil error, want an error")
+	}
+}
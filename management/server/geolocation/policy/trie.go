@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+)
+
+// cidrTrie is a binary (one bit per level) trie over IP prefixes, giving
+// O(prefix-length) insert and longest-prefix-match lookup regardless of how
+// many CIDR ranges are loaded. IPv4 and IPv6 prefixes are kept in separate
+// sub-tries so a v4 lookup can never match a v6-inserted prefix (or vice
+// versa) just because their leading bits happen to coincide.
+type cidrTrie struct {
+	v4root *trieNode
+	v6root *trieNode
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	rule     *Rule // non-nil at a node that terminates a registered prefix
+}
+
+func newCIDRTrie() *cidrTrie {
+	return &cidrTrie{v4root: &trieNode{}, v6root: &trieNode{}}
+}
+
+// insert registers rule under cidr. Later inserts of the same prefix replace
+// the previously registered rule.
+func (t *cidrTrie) insert(cidr string, rule Rule) error {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+	}
+
+	root, bits := t.rootFor(ipNet.IP)
+	ones, _ := ipNet.Mask.Size()
+
+	node := root
+	for i := 0; i < ones; i++ {
+		b := bits[i]
+		if node.children[b] == nil {
+			node.children[b] = &trieNode{}
+		}
+		node = node.children[b]
+	}
+	node.rule = &rule
+
+	return nil
+}
+
+// longestMatch returns the most specific rule whose CIDR contains ip.
+func (t *cidrTrie) longestMatch(ip net.IP) (Rule, bool) {
+	root, bits := t.rootFor(ip)
+
+	node := root
+	var best *Rule
+	for _, b := range bits {
+		if node.rule != nil {
+			best = node.rule
+		}
+		next := node.children[b]
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	if node.rule != nil {
+		best = node.rule
+	}
+
+	if best == nil {
+		return Rule{}, false
+	}
+	return *best, true
+}
+
+func (t *cidrTrie) rootFor(ip net.IP) (*trieNode, []byte) {
+	if v4 := ip.To4(); v4 != nil {
+		return t.v4root, ipToBits(v4)
+	}
+	return t.v6root, ipToBits(ip.To16())
+}
+
+// ipToBits flattens ip into a slice of 0/1 bits, most significant bit first.
+func ipToBits(ip net.IP) []byte {
+	bits := make([]byte, 0, len(ip)*8)
+	for _, octet := range ip {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, (octet>>uint(i))&1)
+		}
+	}
+	return bits
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCidrTrieLongestMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		inserts []string // CIDRs inserted in order, rule ID == CIDR
+		lookup  string
+		wantID  string
+		wantOk  bool
+	}{
+		{
+			name:    "exact v4 match",
+			inserts: []string{"10.0.0.0/8"},
+			lookup:  "10.1.2.3",
+			wantID:  "10.0.0.0/8",
+			wantOk:  true,
+		},
+		{
+			name:    "no match outside range",
+			inserts: []string{"10.0.0.0/8"},
+			lookup:  "192.168.1.1",
+			wantOk:  false,
+		},
+		{
+			name:    "most specific of overlapping prefixes wins",
+			inserts: []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"},
+			lookup:  "10.1.2.3",
+			wantID:  "10.1.2.0/24",
+			wantOk:  true,
+		},
+		{
+			name:    "falls back to less specific prefix",
+			inserts: []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24"},
+			lookup:  "10.1.3.3",
+			wantID:  "10.1.0.0/16",
+			wantOk:  true,
+		},
+		{
+			name:    "v4 /0 matches everything",
+			inserts: []string{"0.0.0.0/0"},
+			lookup:  "8.8.8.8",
+			wantID:  "0.0.0.0/0",
+			wantOk:  true,
+		},
+		{
+			name:    "v6 exact match",
+			inserts: []string{"2001:db8::/32"},
+			lookup:  "2001:db8::1",
+			wantID:  "2001:db8::/32",
+			wantOk:  true,
+		},
+		{
+			name:    "v6 /0 matches everything",
+			inserts: []string{"::/0"},
+			lookup:  "::1",
+			wantID:  "::/0",
+			wantOk:  true,
+		},
+		{
+			name:    "v4 and v6 tries don't cross-match",
+			inserts: []string{"::/0"},
+			lookup:  "10.0.0.1",
+			wantOk:  false,
+		},
+		{
+			name:    "re-inserting the same prefix still matches",
+			inserts: []string{"10.0.0.0/8", "10.0.0.0/8"},
+			lookup:  "10.1.2.3",
+			wantID:  "10.0.0.0/8",
+			wantOk:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trie := newCIDRTrie()
+			for _, cidr := range tt.inserts {
+				if err := trie.insert(cidr, Rule{ID: cidr, CIDR: cidr}); err != nil {
+					t.Fatalf("insert(%q): %v", cidr, err)
+				}
+			}
+
+			rule, ok := trie.longestMatch(net.ParseIP(tt.lookup))
+			if ok != tt.wantOk {
+				t.Fatalf("longestMatch(%q) ok = %v, want %v", tt.lookup, ok, tt.wantOk)
+			}
+			if ok && rule.ID != tt.wantID {
+				t.Fatalf("longestMatch(%q) = %q, want %q", tt.lookup, rule.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestCidrTrieInsertInvalidCIDR(t *testing.T) {
+	trie := newCIDRTrie()
+	if err := trie.insert("not-a-cidr", Rule{ID: "bad"}); err == nil {
+		t.Fatal("insert with invalid CIDR returned nil error")
+	}
+}
@@ -11,8 +11,10 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"strings"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
@@ -21,9 +23,12 @@ import (
 	"github.com/netbirdio/netbird/signal/metrics"
 
 	"github.com/netbirdio/netbird/encryption"
+	"github.com/netbirdio/netbird/management/server/geolocation"
+	"github.com/netbirdio/netbird/management/server/geolocation/policy"
 	"github.com/netbirdio/netbird/signal/proto"
 	"github.com/netbirdio/netbird/signal/server"
 	"github.com/netbirdio/netbird/util"
+	"github.com/netbirdio/netbird/util/sdnotify"
 	"github.com/netbirdio/netbird/version"
 
 	log "github.com/sirupsen/logrus"
@@ -44,7 +49,14 @@ var (
 	defaultSignalSSLDir     string
 	signalCertFile          string
 	signalCertKey           string
+	signalClientCAFile      string
 	enableCompatServer		bool
+	geoPolicyFile           string
+	geoDBDir                string
+	geoDBFile               string
+	geoASNDBFile            string
+	geoProvider             string
+	shutdownTimeout         time.Duration
 
 	signalKaep = grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
 		MinTime:             5 * time.Second,
@@ -101,6 +113,7 @@ var (
 			var opts []grpc.ServerOption
 			var certManager *autocert.Manager
 			var tlsConfig *tls.Config
+			var tlsReloader *tlsCertReloader
 			if signalLetsencryptDomain != "" {
 				certManager, err = encryption.CreateCertManager(signalSSLDir, signalLetsencryptDomain)
 				if err != nil {
@@ -110,11 +123,21 @@ var (
 				opts = append(opts, grpc.Creds(transportCredentials))
 				log.Infof("setting up TLS with LetsEncrypt.")
 			} else if signalCertFile != "" && signalCertKey != "" {
-				tlsConfig, err = loadTLSConfig(signalCertFile, signalCertKey)
+				tlsConfig, tlsReloader, err = loadTLSConfig(signalCertFile, signalCertKey, signalClientCAFile)
 				if err != nil {
 					log.Errorf("cannot load TLS credentials: %v", err)
 					return err
 				}
+				go tlsReloader.watch()
+
+				sighupCh := make(chan os.Signal, 1)
+				signal.Notify(sighupCh, syscall.SIGHUP)
+				go func() {
+					for range sighupCh {
+						tlsReloader.reload("SIGHUP received")
+					}
+				}()
+
 				transportCredentials := credentials.NewTLS(tlsConfig)
 				opts = append(opts, grpc.Creds(transportCredentials))
 				log.Infof("setting up TLS with custom certificates.")
@@ -125,6 +148,29 @@ var (
 				return fmt.Errorf("setup metrics: %v", err)
 			}
 
+			var geoPolicyEngine *policy.Engine
+			if geoPolicyFile != "" {
+				var geo geolocation.Geolocation
+				if geoDBFile != "" {
+					geo, err = geolocation.NewGeolocation(cmd.Context(), geolocation.GeoProvider(geoProvider), geoDBDir, geoDBFile, "", geoASNDBFile, "")
+					if err != nil {
+						return fmt.Errorf("setup geolocation: %v", err)
+					}
+				}
+
+				geoPolicyEngine, err = policy.NewEngine(cmd.Context(), geoPolicyFile, geo)
+				if err != nil {
+					return fmt.Errorf("setup geo policy: %v", err)
+				}
+
+				unaryInterceptor, streamInterceptor, err := geoPolicyEngine.Interceptors(metricsServer.Meter)
+				if err != nil {
+					return fmt.Errorf("setup geo policy interceptors: %v", err)
+				}
+				opts = append(opts, grpc.ChainUnaryInterceptor(unaryInterceptor), grpc.ChainStreamInterceptor(streamInterceptor))
+				log.Infof("geo/ASN/CIDR policy enforcement enabled using rules file: %s", geoPolicyFile)
+			}
+
 			opts = append(opts, signalKaep, signalKasp, grpc.StatsHandler(otelgrpc.NewServerHandler()))
 			grpcServer := grpc.NewServer(opts...)
 
@@ -182,20 +228,50 @@ var (
 			log.Infof("signal server version %s", version.NetbirdVersion())
 			log.Infof("started Signal Service")
 
+			watchdogDone := make(chan struct{})
+			if interval, ok := sdnotify.WatchdogEnabled(); ok {
+				log.Infof("systemd watchdog enabled, pinging every %s", interval/2)
+				go runWatchdog(interval, watchdogDone)
+			}
+			if err := sdnotify.Ready(); err != nil {
+				log.Warnf("failed to notify systemd of readiness: %v", err)
+			}
+
 			SetupCloseHandler()
 
 			<-stopCh
-			if grpcListener != nil {
-				_ = grpcListener.Close()
-				log.Infof("stopped gRPC server")
+			if err := sdnotify.Stopping(); err != nil {
+				log.Warnf("failed to notify systemd of stopping: %v", err)
 			}
+			close(watchdogDone)
+
 			if httpListener != nil {
 				_ = httpListener.Close()
 				log.Infof("stopped HTTP server")
 			}
-			if compatListener != nil {
-				_ = compatListener.Close()
-				log.Infof("stopped gRPC backward compatibility server")
+			// GracefulStop is called unconditionally: when LetsEncrypt is
+			// configured with signalPort == 443, gRPC is multiplexed over
+			// httpListener via grpcServer.ServeHTTP rather than served off
+			// grpcListener/compatListener, so gating on those would skip the
+			// drain entirely for that config. GracefulStop is a no-op if
+			// Serve was never called.
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				log.Infof("stopped gRPC server")
+			case <-time.After(shutdownTimeout):
+				log.Warnf("graceful shutdown of gRPC server timed out after %s, forcing stop", shutdownTimeout)
+				grpcServer.Stop()
+			}
+			if tlsReloader != nil {
+				tlsReloader.Stop()
+			}
+			if geoPolicyEngine != nil {
+				geoPolicyEngine.Stop()
 			}
 
 			ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Second)
@@ -212,6 +288,24 @@ var (
 	}
 )
 
+// runWatchdog pings systemd's watchdog at half the interval it requested, as
+// recommended by sd_watchdog_enabled(3), until done is closed.
+func runWatchdog(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := sdnotify.Watchdog(); err != nil {
+				log.Warnf("failed to send systemd watchdog notification: %v", err)
+			}
+		}
+	}
+}
+
 func grpcHandlerFunc(grpcServer *grpc.Server) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		grpcHeader := strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") ||
@@ -254,23 +348,26 @@ func serveGRPC(grpcServer *grpc.Server, port int) (net.Listener, error) {
 	return listener, nil
 }
 
-func loadTLSConfig(certFile string, certKey string) (*tls.Config, error) {
-	// Load server's certificate and private key
-	serverCert, err := tls.LoadX509KeyPair(certFile, certKey)
+func loadTLSConfig(certFile string, certKey string, clientCAFile string) (*tls.Config, *tlsCertReloader, error) {
+	reloader, err := newTLSCertReloader(certFile, certKey, clientCAFile)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// NewDefaultAppMetrics the credentials and return it
 	config := &tls.Config{
-		Certificates: []tls.Certificate{serverCert},
-		ClientAuth:   tls.NoClientCert,
+		GetCertificate: reloader.GetCertificate,
+		ClientAuth:     tls.NoClientCert,
 		NextProtos: []string{
 			"h2", "http/1.1", // enable HTTP/2
 		},
 	}
+	if clientCAFile != "" {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	config.GetConfigForClient = reloader.GetConfigForClient(config)
 
-	return config, nil
+	return config, reloader, nil
 }
 
 func cpFile(src, dst string) error {
@@ -366,5 +463,12 @@ func init() {
 	runCmd.Flags().StringVar(&signalLetsencryptDomain, "letsencrypt-domain", "", "a domain to issue Let's Encrypt certificate for. Enables TLS using Let's Encrypt. Will fetch and renew certificate, and run the server with TLS")
 	runCmd.Flags().StringVar(&signalCertFile, "cert-file", "", "Location of your SSL certificate. Can be used when you have an existing certificate and don't want a new certificate be generated automatically. If letsencrypt-domain is specified this property has no effect")
 	runCmd.Flags().StringVar(&signalCertKey, "cert-key", "", "Location of your SSL certificate private key. Can be used when you have an existing certificate and don't want a new certificate be generated automatically. If letsencrypt-domain is specified this property has no effect")
+	runCmd.Flags().StringVar(&signalClientCAFile, "client-ca-file", "", "Location of a CA bundle used to verify client certificates. When set, the server requests and, if presented, validates a client certificate against this CA. The CA bundle is reloaded along with the server certificate.")
 	runCmd.Flags().BoolVar(&enableCompatServer, "enable-compat-server", false, "Enables a second server which listens on port 10000 for compatability with older, pre-existing clients. If port is set to 10000, this setting has no effect.")
+	runCmd.Flags().StringVar(&geoPolicyFile, "geo-policy-file", "", "Location of a YAML/JSON file with allow/deny rules (by country, continent, ASN or CIDR) used to geo/ASN-fence which peers may open a Signal stream. The file is hot-reloaded on change. Disabled when empty.")
+	runCmd.Flags().StringVar(&geoDBDir, "geo-db-dir", "", "Directory containing the geolocation database used to resolve country/continent/ASN for --geo-policy-file rules. Required only if the rules file uses country, continent or asn matchers.")
+	runCmd.Flags().StringVar(&geoDBFile, "geo-db-file", "", "Filename, within --geo-db-dir, of the geolocation database used for --geo-policy-file rules.")
+	runCmd.Flags().StringVar(&geoASNDBFile, "geo-asn-db-file", "", "Filename, within --geo-db-dir, of a GeoLite2-ASN (or equivalent) database used to resolve ASN for asn-keyed --geo-policy-file rules. Only supported with --geo-provider=maxmind; required for those rules to match, since ASN is otherwise never populated.")
+	runCmd.Flags().StringVar(&geoProvider, "geo-provider", "maxmind", "Geolocation database provider for --geo-policy-file rules. One of: maxmind, ip2location, dbip.")
+	runCmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", 25*time.Second, "Maximum time to wait for in-flight peer streams to drain during a graceful shutdown before forcibly stopping the gRPC server.")
 }
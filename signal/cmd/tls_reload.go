@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// tlsCertReloader watches a certificate/key pair (and, optionally, a client CA
+// bundle) on disk and keeps an in-memory tls.Config up to date without
+// requiring the gRPC server to be restarted. It reuses the periodic SHA-256
+// recheck of the geolocation database reloaders and adds an fsnotify watch
+// for the common case; the recheck remains as a fallback for
+// filesystems/editors that don't emit reliable events (e.g. atomic renames
+// from a certbot hook).
+type tlsCertReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mux        sync.RWMutex
+	cert       *tls.Certificate
+	certSHA256 []byte
+
+	clientCAPool *x509.CertPool
+	caSHA256     []byte
+
+	reloadCheckInterval time.Duration
+	stopCh              chan struct{}
+}
+
+func newTLSCertReloader(certFile, keyFile, clientCAFile string) (*tlsCertReloader, error) {
+	r := &tlsCertReloader{
+		certFile:            certFile,
+		keyFile:             keyFile,
+		clientCAFile:        clientCAFile,
+		reloadCheckInterval: 30 * time.Second,
+		stopCh:              make(chan struct{}),
+	}
+
+	if err := r.loadCert(); err != nil {
+		return nil, err
+	}
+
+	if clientCAFile != "" {
+		if err := r.loadClientCAs(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *tlsCertReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.cert, nil
+}
+
+// GetConfigForClient implements tls.Config.GetConfigForClient. It returns a
+// shallow copy of the base config with the currently loaded client CA pool so
+// that a rotated CA takes effect on the next handshake.
+func (r *tlsCertReloader) GetConfigForClient(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mux.RLock()
+		defer r.mux.RUnlock()
+
+		cfg := base.Clone()
+		if r.clientCAFile != "" {
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			cfg.ClientCAs = r.clientCAPool
+		}
+		return cfg, nil
+	}
+}
+
+func (r *tlsCertReloader) loadCert() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load keypair: %w", err)
+	}
+
+	sum, err := sha256Files(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.cert = &cert
+	r.certSHA256 = sum
+
+	return nil
+}
+
+func (r *tlsCertReloader) loadClientCAs() error {
+	pemBytes, err := os.ReadFile(r.clientCAFile)
+	if err != nil {
+		return fmt.Errorf("read client CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return fmt.Errorf("no valid certificates found in %s", r.clientCAFile)
+	}
+
+	sum := sha256.Sum256(pemBytes)
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.clientCAPool = pool
+	r.caSHA256 = sum[:]
+
+	return nil
+}
+
+// reload re-reads the cert/key pair and, if configured, the client CA bundle
+// from disk, replacing them atomically behind the mutex. Existing streams
+// keep using the tls.Config they negotiated with; only new handshakes pick up
+// the updated material.
+func (r *tlsCertReloader) reload(reason string) {
+	log.Infof("reloading TLS certificate (%s)", reason)
+
+	if err := r.loadCert(); err != nil {
+		log.Errorf("failed to reload TLS certificate: %v", err)
+		return
+	}
+
+	if r.clientCAFile != "" {
+		if err := r.loadClientCAs(); err != nil {
+			log.Errorf("failed to reload client CA pool: %v", err)
+			return
+		}
+	}
+
+	log.Infof("successfully reloaded TLS certificate")
+}
+
+// watch runs the fsnotify loop plus the periodic SHA-256 fallback check. It
+// blocks until Stop is called.
+func (r *tlsCertReloader) watch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Errorf("failed to create TLS cert watcher, falling back to periodic recheck only: %v", err)
+	} else {
+		defer func() {
+			_ = watcher.Close()
+		}()
+		for _, f := range r.watchedFiles() {
+			if err := watcher.Add(f); err != nil {
+				log.Errorf("failed to watch %s for TLS changes: %v", f, err)
+			}
+		}
+	}
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	if watcher != nil {
+		events = watcher.Events
+		errs = watcher.Errors
+	}
+
+	ticker := time.NewTicker(r.reloadCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				r.reload(fmt.Sprintf("fsnotify event on %s", event.Name))
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			log.Errorf("TLS cert watcher error: %v", err)
+		case <-ticker.C:
+			if r.changed() {
+				r.reload("periodic SHA-256 recheck")
+			}
+		}
+	}
+}
+
+func (r *tlsCertReloader) watchedFiles() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	return files
+}
+
+func (r *tlsCertReloader) changed() bool {
+	certSum, err := sha256Files(r.certFile, r.keyFile)
+	if err != nil {
+		log.Errorf("failed to calculate sha256 sum for TLS cert/key: %v", err)
+		return false
+	}
+
+	r.mux.RLock()
+	certChanged := !bytes.Equal(r.certSHA256, certSum)
+	r.mux.RUnlock()
+
+	if certChanged {
+		return true
+	}
+
+	if r.clientCAFile == "" {
+		return false
+	}
+
+	caBytes, err := os.ReadFile(r.clientCAFile)
+	if err != nil {
+		log.Errorf("failed to read client CA file '%s': %v", r.clientCAFile, err)
+		return false
+	}
+	caSum := sha256.Sum256(caBytes)
+
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return !bytes.Equal(r.caSHA256, caSum[:])
+}
+
+func (r *tlsCertReloader) Stop() {
+	close(r.stopCh)
+}
+
+func sha256Files(paths ...string) ([]byte, error) {
+	h := sha256.New()
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", p, err)
+		}
+		if _, err := h.Write(b); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
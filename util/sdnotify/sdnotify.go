@@ -0,0 +1,56 @@
+// Package sdnotify sends systemd readiness/watchdog/stopping notifications
+// over the NOTIFY_SOCKET protocol (sd_notify(3)). It's a no-op on non-Linux
+// platforms so callers don't need to guard every call with a build tag.
+package sdnotify
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Ready tells systemd the service finished starting up. Typical use is
+// calling it once all listeners are up, right before blocking on the
+// service's main loop.
+func Ready() error {
+	return notify("READY=1")
+}
+
+// Stopping tells systemd the service is beginning a graceful shutdown.
+func Stopping() error {
+	return notify("STOPPING=1")
+}
+
+// Watchdog pings systemd to reset the watchdog timer armed via
+// WatchdogEnabled/WATCHDOG_USEC. Call it more often than the interval
+// WatchdogEnabled returns, or systemd will consider the service hung.
+func Watchdog() error {
+	return notify("WATCHDOG=1")
+}
+
+// WatchdogEnabled reports whether systemd armed a watchdog for this service
+// (Type=notify with WatchdogSec= set in the unit file) and, if so, the
+// watchdog timeout; Watchdog must be called more often than this interval.
+// It follows the sd_watchdog_enabled
+// convention: WATCHDOG_USEC must be set and, when WATCHDOG_PID is also set,
+// it must match our PID.
+func WatchdogEnabled() (time.Duration, bool) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, false
+	}
+
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || pid != os.Getpid() {
+			return 0, false
+		}
+	}
+
+	us, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || us <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(us) * time.Microsecond, true
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+// notify sends state over the NOTIFY_SOCKET datagram socket, per sd_notify(3).
+// It's a no-op (returning nil) when NOTIFY_SOCKET isn't set, e.g. when the
+// process wasn't started by systemd or the unit isn't Type=notify.
+func notify(state string) error {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return nil
+	}
+
+	// An @ prefix denotes a Linux abstract namespace socket, represented in
+	// Go's net package as a leading NUL byte rather than '@'.
+	if strings.HasPrefix(socketPath, "@") {
+		socketPath = "\x00" + socketPath[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
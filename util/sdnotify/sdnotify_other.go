@@ -0,0 +1,9 @@
+//go:build !linux
+
+package sdnotify
+
+// notify is a no-op on non-Linux platforms; systemd readiness/watchdog
+// notification only applies on Linux.
+func notify(state string) error {
+	return nil
+}
@@ -0,0 +1,95 @@
+package sdnotify
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWatchdogEnabled(t *testing.T) {
+	otherPID := os.Getpid() + 1
+
+	tests := []struct {
+		name         string
+		watchdogUsec string
+		watchdogPID  string // set to "" (treated as unset) when empty
+		wantInterval time.Duration
+		wantOk       bool
+	}{
+		{
+			name:         "WATCHDOG_USEC empty",
+			watchdogUsec: "",
+			wantOk:       false,
+		},
+		{
+			name:         "enabled with no WATCHDOG_PID",
+			watchdogUsec: "30000000",
+			wantInterval: 30 * time.Second,
+			wantOk:       true,
+		},
+		{
+			name:         "WATCHDOG_PID matches our pid",
+			watchdogUsec: "30000000",
+			watchdogPID:  strconv.Itoa(os.Getpid()),
+			wantInterval: 30 * time.Second,
+			wantOk:       true,
+		},
+		{
+			name:         "WATCHDOG_PID does not match our pid",
+			watchdogUsec: "30000000",
+			watchdogPID:  strconv.Itoa(otherPID),
+			wantOk:       false,
+		},
+		{
+			name:         "WATCHDOG_PID is not a number",
+			watchdogUsec: "30000000",
+			watchdogPID:  "not-a-pid",
+			wantOk:       false,
+		},
+		{
+			name:         "WATCHDOG_USEC is not a number",
+			watchdogUsec: "not-a-number",
+			wantOk:       false,
+		},
+		{
+			name:         "WATCHDOG_USEC is zero",
+			watchdogUsec: "0",
+			wantOk:       false,
+		},
+		{
+			name:         "WATCHDOG_USEC is negative",
+			watchdogUsec: "-1",
+			wantOk:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.watchdogUsec)
+			t.Setenv("WATCHDOG_PID", tt.watchdogPID)
+
+			interval, ok := WatchdogEnabled()
+			if ok != tt.wantOk {
+				t.Fatalf("WatchdogEnabled() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && interval != tt.wantInterval {
+				t.Fatalf("WatchdogEnabled() interval = %v, want %v", interval, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestWatchdogEnabledExampleInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", fmt.Sprintf("%d", (15*time.Second).Microseconds()))
+	os.Unsetenv("WATCHDOG_PID")
+
+	interval, ok := WatchdogEnabled()
+	if !ok {
+		t.Fatal("WatchdogEnabled() ok = false, want true")
+	}
+	if interval != 15*time.Second {
+		t.Fatalf("WatchdogEnabled() interval = %v, want 15s", interval)
+	}
+}